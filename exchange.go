@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+var exchangeName = flag.String(
+	"exchange", "coinbase", "Exchange to stream from. Supports coinbase, binance, kraken, and bitvavo.")
+
+var compare = flag.String(
+	"compare", "", "Second exchange to stream alongside --exchange, splitting the candle pane to compare spreads.")
+
+// Trade is a single executed trade, normalized across exchanges.
+type Trade struct {
+	Side  string
+	Price float64
+	Time  time.Time
+}
+
+// ExchangeFeed is a live market data source for a single exchange. Each
+// implementation speaks that exchange's native websocket protocol and
+// normalizes trades into Trade.
+type ExchangeFeed interface {
+	// Name is the short identifier used with --exchange/--compare.
+	Name() string
+
+	// Products lists the product ids (e.g. "BTC-USD") this feed can stream.
+	Products() []string
+
+	// Subscribe starts streaming trades for product. The returned channel
+	// is closed if the feed is permanently shut down.
+	Subscribe(product string) <-chan Trade
+}
+
+// NewExchangeFeed builds the feed named by name.
+func NewExchangeFeed(ctx context.Context, name string) (ExchangeFeed, error) {
+	switch name {
+	case "coinbase":
+		return NewCoinbaseFeed(ctx), nil
+	case "binance":
+		return NewBinanceFeed(ctx), nil
+	case "kraken":
+		return NewKrakenFeed(ctx), nil
+	case "bitvavo":
+		return NewBitvavoFeed(ctx), nil
+	default:
+		return nil, fmt.Errorf("unknown exchange %q", name)
+	}
+}
+
+// ValidateProduct checks that product is one feed actually advertises via
+// Products(), returning a clear error instead of letting callers subscribe
+// to a product the exchange doesn't support (which streams nothing, with no
+// indication why).
+func ValidateProduct(feed ExchangeFeed, product string) error {
+	for _, p := range feed.Products() {
+		if p == product {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s does not support product %q (supports %v)", feed.Name(), product, feed.Products())
+}
+
+// nextBackoff doubles d, capped at maxBackoff, for feeds that reconnect on
+// every error rather than running the full MarketDataClient machinery.
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// GetMessages subscribes to feed for product and adapts its Trade channel
+// into the plain channel the main loop selects on.
+func GetMessages(feed ExchangeFeed, product string) chan Trade {
+	out := make(chan Trade)
+	go func() {
+		defer close(out)
+		for trade := range feed.Subscribe(product) {
+			out <- trade
+		}
+	}()
+	return out
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+const binanceFeedURL = "wss://stream.binance.com:9443/ws"
+
+// BinanceFeed is the ExchangeFeed implementation for Binance's raw trade
+// stream.
+type BinanceFeed struct {
+	ctx context.Context
+}
+
+func NewBinanceFeed(ctx context.Context) *BinanceFeed {
+	return &BinanceFeed{ctx: ctx}
+}
+
+func (f *BinanceFeed) Name() string { return "binance" }
+
+func (f *BinanceFeed) Products() []string {
+	return []string{"BTC-USD", "ETH-USD", "LTC-USD", "BCH-USD"}
+}
+
+// binanceTrade mirrors the subset of Binance's <symbol>@trade payload gct
+// cares about.
+type binanceTrade struct {
+	Price        string `json:"p"`
+	BuyerIsMaker bool   `json:"m"` // true means the buyer was passive, i.e. an aggressive sell.
+	TradeTime    int64  `json:"T"`
+}
+
+func binanceSymbol(product string) string {
+	return strings.ToLower(strings.Replace(product, "-", "", 1))
+}
+
+func (f *BinanceFeed) Subscribe(product string) <-chan Trade {
+	out := make(chan Trade)
+	url := fmt.Sprintf("%s/%s@trade", binanceFeedURL, binanceSymbol(product))
+
+	go func() {
+		defer close(out)
+		backoff := minBackoff
+		for f.ctx.Err() == nil {
+			conn, _, err := ws.DefaultDialer.DialContext(f.ctx, url, nil)
+			if err != nil {
+				println(err.Error())
+				time.Sleep(backoff)
+				backoff = nextBackoff(backoff)
+				continue
+			}
+			backoff = minBackoff
+
+			for {
+				var raw json.RawMessage
+				if err := conn.ReadJSON(&raw); err != nil {
+					println(err.Error())
+					break
+				}
+				var trade binanceTrade
+				if err := json.Unmarshal(raw, &trade); err != nil {
+					continue
+				}
+				price, err := strconv.ParseFloat(trade.Price, 64)
+				if err != nil {
+					continue
+				}
+				side := "buy"
+				if trade.BuyerIsMaker {
+					side = "sell"
+				}
+				out <- Trade{
+					Side:  side,
+					Price: price,
+					Time:  time.UnixMilli(trade.TradeTime),
+				}
+			}
+			conn.Close()
+
+			if f.ctx.Err() != nil {
+				return
+			}
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff)
+		}
+	}()
+
+	return out
+}
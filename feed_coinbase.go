@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+const advancedTradeFeedURL = "wss://advanced-trade-ws.coinbase.com"
+
+var apiKey = flag.String(
+	"api_key", "", "Coinbase Advanced Trade API key, for authenticated channels. Public channels work without one.")
+
+var apiSecret = flag.String(
+	"api_secret", "", "Coinbase Advanced Trade API secret, paired with --api_key.")
+
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+	pingPeriod = 15 * time.Second
+)
+
+// subscribeRequest mirrors the Advanced Trade v2 subscribe/unsubscribe
+// envelope. Signature/Key/Timestamp are only populated when the client was
+// configured with API credentials; the ticker and market_trades channels
+// also work unauthenticated.
+type subscribeRequest struct {
+	Type       string   `json:"type"`
+	ProductIds []string `json:"product_ids"`
+	Channel    string   `json:"channel"`
+	Signature  string   `json:"signature,omitempty"`
+	Key        string   `json:"api_key,omitempty"`
+	Timestamp  string   `json:"timestamp,omitempty"`
+}
+
+// feedMessage is the common envelope every Advanced Trade v2 message is
+// wrapped in; Events is left raw so each channel can decode it to its own
+// shape.
+type feedMessage struct {
+	Channel     string            `json:"channel"`
+	ClientId    string            `json:"client_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	SequenceNum int64             `json:"sequence_num"`
+	Events      []json.RawMessage `json:"events"`
+}
+
+type tickerEvent struct {
+	Type    string `json:"type"`
+	Tickers []struct {
+		ProductId string `json:"product_id"`
+		Price     string `json:"price"`
+		Side      string `json:"side"`
+		Time      string `json:"time"`
+	} `json:"tickers"`
+}
+
+// l2Event mirrors the subset of the Advanced Trade v2 l2_data payload gct
+// needs to maintain a book.
+type l2Event struct {
+	Type    string `json:"type"`
+	Updates []struct {
+		Side        string `json:"side"`
+		PriceLevel  string `json:"price_level"`
+		NewQuantity string `json:"new_quantity"`
+	} `json:"updates"`
+}
+
+// CoinbaseFeed is the ExchangeFeed implementation for Coinbase, backed by a
+// MarketDataClient per subscribed product.
+type CoinbaseFeed struct {
+	ctx context.Context
+
+	mu      sync.Mutex
+	clients map[string]*MarketDataClient
+}
+
+// NewCoinbaseFeed builds a Coinbase ExchangeFeed. Subscribing cancels with
+// ctx.
+func NewCoinbaseFeed(ctx context.Context) *CoinbaseFeed {
+	return &CoinbaseFeed{ctx: ctx, clients: map[string]*MarketDataClient{}}
+}
+
+func (f *CoinbaseFeed) Name() string { return "coinbase" }
+
+func (f *CoinbaseFeed) Products() []string {
+	return []string{"BTC-USD", "ETH-USD", "LTC-USD", "BCH-USD"}
+}
+
+func (f *CoinbaseFeed) client(product string) *MarketDataClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if client, ok := f.clients[product]; ok {
+		return client
+	}
+	client := NewMarketDataClient(f.ctx, product)
+	f.clients[product] = client
+	return client
+}
+
+func (f *CoinbaseFeed) Subscribe(product string) <-chan Trade {
+	out := make(chan Trade)
+	f.client(product).Run(out)
+	return out
+}
+
+// OrderBook returns the level-2 book being maintained for product.
+// Subscribe must have been called for product first so the underlying
+// MarketDataClient is actually running and feeding it updates.
+func (f *CoinbaseFeed) OrderBook(product string) *OrderBook {
+	return f.client(product).book
+}
+
+// MarketDataClient speaks the Coinbase Advanced Trade v2 market data
+// websocket protocol: JSON-over-websocket, a heartbeats channel, sequence
+// number gap detection, and ping/pong keepalive. It replaces the old
+// ws-feed.gdax.com ticker client.
+type MarketDataClient struct {
+	product string
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	conn    *ws.Conn
+	lastSeq map[string]int64
+	backoff time.Duration
+	book    *OrderBook
+}
+
+// NewMarketDataClient builds a client for product (e.g. "BTC-USD"). Call Run
+// to connect and start streaming; canceling ctx stops it for good.
+func NewMarketDataClient(ctx context.Context, product string) *MarketDataClient {
+	ctx, cancel := context.WithCancel(ctx)
+	return &MarketDataClient{
+		product: product,
+		ctx:     ctx,
+		cancel:  cancel,
+		lastSeq: map[string]int64{},
+		backoff: minBackoff,
+		book:    NewOrderBook(),
+	}
+}
+
+func (c *MarketDataClient) Close() {
+	c.cancel()
+}
+
+// sign produces the HMAC-SHA256 signature Advanced Trade expects for
+// authenticated channel subscriptions, hex-encoded over
+// timestamp+channel+product_ids.
+func (c *MarketDataClient) sign(channel, timestamp string) string {
+	if *apiSecret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(*apiSecret))
+	mac.Write([]byte(timestamp + channel + c.product))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *MarketDataClient) subscribe(conn *ws.Conn, channel string) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := subscribeRequest{
+		Type:       "subscribe",
+		ProductIds: []string{c.product},
+		Channel:    channel,
+	}
+	if *apiKey != "" {
+		req.Key = *apiKey
+		req.Timestamp = timestamp
+		req.Signature = c.sign(channel, timestamp)
+	}
+	return conn.WriteJSON(req)
+}
+
+func (c *MarketDataClient) connect() (*ws.Conn, error) {
+	conn, _, err := ws.DefaultDialer.DialContext(c.ctx, advancedTradeFeedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range []string{"heartbeats", "ticker", "market_trades", "level2"} {
+		if err := c.subscribe(conn, channel); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("subscribing to %s: %v", channel, err)
+		}
+	}
+	return conn, nil
+}
+
+// Run connects and streams ticker updates as Trades to out until ctx is
+// canceled. It reconnects and resubscribes automatically on any error, with
+// exponential backoff and jitter.
+func (c *MarketDataClient) Run(out chan Trade) {
+	go func() {
+		defer close(out)
+		for {
+			if c.ctx.Err() != nil {
+				return
+			}
+
+			conn, err := c.connect()
+			if err != nil {
+				println(err.Error())
+				c.sleepBackoff()
+				continue
+			}
+			c.conn = conn
+			c.backoff = minBackoff
+			c.runKeepalive(conn)
+
+			c.readLoop(conn, out)
+			conn.Close()
+
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.sleepBackoff()
+		}
+	}()
+}
+
+func (c *MarketDataClient) sleepBackoff() {
+	jitter := time.Duration(rand.Int63n(int64(c.backoff)))
+	select {
+	case <-time.After(c.backoff/2 + jitter/2):
+	case <-c.ctx.Done():
+	}
+	c.backoff *= 2
+	if c.backoff > maxBackoff {
+		c.backoff = maxBackoff
+	}
+}
+
+// runKeepalive pings on an interval; the gorilla/websocket library answers
+// pongs for us via SetPongHandler, which just needs to exist so reads don't
+// treat a pong as a protocol error.
+func (c *MarketDataClient) runKeepalive(conn *ws.Conn) {
+	conn.SetPongHandler(func(string) error { return nil })
+	go func() {
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := conn.WriteControl(ws.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+					return
+				}
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (c *MarketDataClient) readLoop(conn *ws.Conn, out chan Trade) {
+	for {
+		var msg feedMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			println(err.Error())
+			return
+		}
+
+		if last, ok := c.lastSeq[msg.Channel]; ok && msg.SequenceNum > last+1 {
+			println(fmt.Sprintf("gap in %s sequence: had %d, got %d", msg.Channel, last, msg.SequenceNum))
+		}
+		c.lastSeq[msg.Channel] = msg.SequenceNum
+
+		switch msg.Channel {
+		case "heartbeats":
+			// Nothing to do; receiving one at all is proof the connection
+			// is alive.
+		case "ticker":
+			c.handleTicker(msg, out)
+		case "l2_data":
+			c.handleL2(msg)
+		case "market_trades":
+			// Handled by the portfolio subsystem via the ticker channel;
+			// gct doesn't need per-trade size/maker-side detail today.
+		}
+	}
+}
+
+func (c *MarketDataClient) handleTicker(msg feedMessage, out chan Trade) {
+	for _, raw := range msg.Events {
+		var event tickerEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			continue
+		}
+		for _, t := range event.Tickers {
+			price, err := strconv.ParseFloat(t.Price, 64)
+			if err != nil {
+				continue
+			}
+			parsedTime, err := time.Parse(time.RFC3339, t.Time)
+			if err != nil {
+				parsedTime = time.Now()
+			}
+			out <- Trade{
+				Side:  t.Side,
+				Price: price,
+				Time:  parsedTime,
+			}
+		}
+	}
+}
+
+func (c *MarketDataClient) handleL2(msg feedMessage) {
+	for _, raw := range msg.Events {
+		var event l2Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			continue
+		}
+		for _, u := range event.Updates {
+			price, err := strconv.ParseFloat(u.PriceLevel, 64)
+			if err != nil {
+				continue
+			}
+			size, err := strconv.ParseFloat(u.NewQuantity, 64)
+			if err != nil {
+				continue
+			}
+			c.book.Update(u.Side, price, size)
+		}
+	}
+}
+
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+const krakenFeedURL = "wss://ws.kraken.com"
+
+// KrakenFeed is the ExchangeFeed implementation for Kraken's public trade
+// channel.
+type KrakenFeed struct {
+	ctx context.Context
+}
+
+func NewKrakenFeed(ctx context.Context) *KrakenFeed {
+	return &KrakenFeed{ctx: ctx}
+}
+
+func (f *KrakenFeed) Name() string { return "kraken" }
+
+func (f *KrakenFeed) Products() []string {
+	return []string{"BTC-USD", "ETH-USD", "LTC-USD", "BCH-USD"}
+}
+
+// krakenPair turns "BTC-USD" into Kraken's "XBT/USD" pair notation.
+func krakenPair(product string) string {
+	parts := strings.SplitN(product, "-", 2)
+	base, quote := parts[0], parts[1]
+	if base == "BTC" {
+		base = "XBT"
+	}
+	return base + "/" + quote
+}
+
+type krakenSubscribeRequest struct {
+	Event        string                 `json:"event"`
+	Pair         []string               `json:"pair"`
+	Subscription map[string]interface{} `json:"subscription"`
+}
+
+func (f *KrakenFeed) Subscribe(product string) <-chan Trade {
+	out := make(chan Trade)
+	pair := krakenPair(product)
+
+	go func() {
+		defer close(out)
+		backoff := minBackoff
+		for f.ctx.Err() == nil {
+			conn, _, err := ws.DefaultDialer.DialContext(f.ctx, krakenFeedURL, nil)
+			if err != nil {
+				println(err.Error())
+				time.Sleep(backoff)
+				backoff = nextBackoff(backoff)
+				continue
+			}
+
+			req := krakenSubscribeRequest{
+				Event:        "subscribe",
+				Pair:         []string{pair},
+				Subscription: map[string]interface{}{"name": "trade"},
+			}
+			if err := conn.WriteJSON(req); err != nil {
+				println(err.Error())
+				conn.Close()
+				time.Sleep(backoff)
+				backoff = nextBackoff(backoff)
+				continue
+			}
+			backoff = minBackoff
+
+			f.readLoop(conn, out)
+			conn.Close()
+
+			if f.ctx.Err() != nil {
+				return
+			}
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff)
+		}
+	}()
+
+	return out
+}
+
+// Kraken trade channel messages are heterogeneous JSON arrays:
+// [channelID, [[price, volume, time, side, ...], ...], "trade", pair]. Status
+// and heartbeat messages are JSON objects instead, so we read each message as
+// raw JSON first and only then try to shape it as an array; that failing is
+// expected and skipped, but a ReadJSON error means the connection itself is
+// dead and must bubble up to the reconnect loop.
+func (f *KrakenFeed) readLoop(conn *ws.Conn, out chan Trade) {
+	for {
+		var msg json.RawMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			println(err.Error())
+			return
+		}
+
+		var raw []json.RawMessage
+		if err := json.Unmarshal(msg, &raw); err != nil {
+			continue
+		}
+		if len(raw) < 3 {
+			continue
+		}
+
+		var trades [][]string
+		if err := json.Unmarshal(raw[1], &trades); err != nil {
+			continue
+		}
+		for _, t := range trades {
+			if len(t) < 4 {
+				continue
+			}
+			price, err := strconv.ParseFloat(t[0], 64)
+			if err != nil {
+				continue
+			}
+			unixSeconds, err := strconv.ParseFloat(t[2], 64)
+			if err != nil {
+				continue
+			}
+			side := "buy"
+			if t[3] == "s" {
+				side = "sell"
+			}
+			out <- Trade{
+				Side:  side,
+				Price: price,
+				Time:  time.Unix(0, int64(unixSeconds*float64(time.Second))),
+			}
+		}
+	}
+}
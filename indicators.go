@@ -0,0 +1,352 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+var indicatorsFlag = flag.String(
+	"indicators", "", "Comma-separated indicators to preconfigure, e.g. \"ema:20,bb:20:2,rsi:14\". Supports ema, sma, bb, rsi, and macd.")
+
+// Indicator computes a derived series from candle buckets and knows how to
+// render it.
+type Indicator interface {
+	// Compute derives a value per bucket, oldest first, parallel to buckets.
+	Compute(buckets []*Bucket) []float64
+
+	// Render draws the computed values into frame.
+	Render(frame Frame, values []float64)
+}
+
+// activeIndicators holds the indicators currently toggled on, keyed by the
+// short name used in --indicators and by the e/b/r/m keybindings.
+var activeIndicators = map[string]Indicator{}
+
+// toggleIndicator adds name's default indicator if it isn't active, or
+// removes it if it is.
+func toggleIndicator(name string) {
+	if _, ok := activeIndicators[name]; ok {
+		delete(activeIndicators, name)
+		return
+	}
+	if ind := newDefaultIndicator(name); ind != nil {
+		activeIndicators[name] = ind
+	}
+}
+
+func newDefaultIndicator(name string) Indicator {
+	switch name {
+	case "ema":
+		return &EMAIndicator{N: 20}
+	case "sma":
+		return &SMAIndicator{N: 20}
+	case "bb":
+		return &BollingerIndicator{N: 20, K: 2}
+	case "rsi":
+		return &RSIIndicator{N: 14}
+	case "macd":
+		return &MACDIndicator{Fast: 12, Slow: 26, Signal: 9}
+	default:
+		return nil
+	}
+}
+
+// parseIndicators parses a --indicators spec like "ema:20,bb:20:2,rsi:14"
+// into the map of indicators it preconfigures.
+func parseIndicators(spec string) map[string]Indicator {
+	indicators := map[string]Indicator{}
+	if spec == "" {
+		return indicators
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(entry, ":")
+		name := parts[0]
+		args := parts[1:]
+		ind := parseIndicator(name, args)
+		if ind != nil {
+			indicators[name] = ind
+		}
+	}
+	return indicators
+}
+
+func parseIndicator(name string, args []string) Indicator {
+	intArg := func(i int, def int) int {
+		if i >= len(args) {
+			return def
+		}
+		n, err := strconv.Atoi(args[i])
+		if err != nil {
+			return def
+		}
+		return n
+	}
+	floatArg := func(i int, def float64) float64 {
+		if i >= len(args) {
+			return def
+		}
+		f, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return def
+		}
+		return f
+	}
+
+	switch name {
+	case "ema":
+		return &EMAIndicator{N: intArg(0, 20)}
+	case "sma":
+		return &SMAIndicator{N: intArg(0, 20)}
+	case "bb":
+		return &BollingerIndicator{N: intArg(0, 20), K: floatArg(1, 2)}
+	case "rsi":
+		return &RSIIndicator{N: intArg(0, 14)}
+	case "macd":
+		return &MACDIndicator{Fast: intArg(0, 12), Slow: intArg(1, 26), Signal: intArg(2, 9)}
+	default:
+		return nil
+	}
+}
+
+func closes(buckets []*Bucket) []float64 {
+	values := make([]float64, len(buckets))
+	for i, b := range buckets {
+		values[i] = b.Close
+	}
+	return values
+}
+
+// ema computes the exponential moving average of values over window n:
+// ema[i] = price[i]*k + ema[i-1]*(1-k), k = 2/(n+1).
+func ema(values []float64, n int) []float64 {
+	out := make([]float64, len(values))
+	if len(values) == 0 {
+		return out
+	}
+	k := 2.0 / float64(n+1)
+	out[0] = values[0]
+	for i := 1; i < len(values); i++ {
+		out[i] = values[i]*k + out[i-1]*(1-k)
+	}
+	return out
+}
+
+func sma(values []float64, n int) []float64 {
+	out := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= n {
+			sum -= values[i-n]
+		}
+		window := i + 1
+		if window > n {
+			window = n
+		}
+		out[i] = sum / float64(window)
+	}
+	return out
+}
+
+// overlayPriceLine plots values onto frame using the same price-to-column
+// mapping DrawCandles uses, so overlays line up with the candles beneath
+// them.
+func overlayPriceLine(frame Frame, values []float64, lowerBound, upperBound float64, fg termbox.Attribute) {
+	if upperBound <= lowerBound {
+		return
+	}
+	priceSpread := upperBound - lowerBound
+	for line, j := 1, len(values)-1; j >= 0 && line < frame.h; line, j = line+1, j-1 {
+		x := 1 + (values[j]-lowerBound)/priceSpread*float64(frame.w-2)
+		frame.SetCell(int(x), line, '•', fg, termbox.ColorDefault)
+	}
+}
+
+// EMAIndicator overlays an exponential moving average on the candle pane.
+type EMAIndicator struct {
+	N          int
+	lowerBound float64
+	upperBound float64
+}
+
+func (e *EMAIndicator) Compute(buckets []*Bucket) []float64 {
+	e.lowerBound, e.upperBound = priceBounds(buckets)
+	return ema(closes(buckets), e.N)
+}
+
+func (e *EMAIndicator) Render(frame Frame, values []float64) {
+	overlayPriceLine(frame, values, e.lowerBound, e.upperBound, termbox.ColorYellow)
+}
+
+// SMAIndicator overlays a simple moving average on the candle pane.
+type SMAIndicator struct {
+	N          int
+	lowerBound float64
+	upperBound float64
+}
+
+func (s *SMAIndicator) Compute(buckets []*Bucket) []float64 {
+	s.lowerBound, s.upperBound = priceBounds(buckets)
+	return sma(closes(buckets), s.N)
+}
+
+func (s *SMAIndicator) Render(frame Frame, values []float64) {
+	overlayPriceLine(frame, values, s.lowerBound, s.upperBound, termbox.ColorCyan)
+}
+
+// BollingerIndicator overlays Bollinger Bands (mean ± k·σ over N buckets) on
+// the candle pane. Compute returns the midline and stores the upper/lower
+// bands, from the same window statistics, for Render to draw.
+type BollingerIndicator struct {
+	N          int
+	K          float64
+	lowerBound float64
+	upperBound float64
+	upperBand  []float64
+	lowerBand  []float64
+}
+
+func (b *BollingerIndicator) Compute(buckets []*Bucket) []float64 {
+	b.lowerBound, b.upperBound = priceBounds(buckets)
+	values := closes(buckets)
+	mid := sma(values, b.N)
+
+	b.upperBand = make([]float64, len(values))
+	b.lowerBand = make([]float64, len(values))
+	for i := range values {
+		start := i - b.N + 1
+		if start < 0 {
+			start = 0
+		}
+		window := values[start : i+1]
+		variance := 0.0
+		for _, v := range window {
+			d := v - mid[i]
+			variance += d * d
+		}
+		stddev := math.Sqrt(variance / float64(len(window)))
+		b.upperBand[i] = mid[i] + b.K*stddev
+		b.lowerBand[i] = mid[i] - b.K*stddev
+	}
+	return mid
+}
+
+func (b *BollingerIndicator) Render(frame Frame, values []float64) {
+	overlayPriceLine(frame, values, b.lowerBound, b.upperBound, termbox.ColorMagenta)
+	overlayPriceLine(frame, b.upperBand, b.lowerBound, b.upperBound, termbox.ColorMagenta)
+	overlayPriceLine(frame, b.lowerBand, b.lowerBound, b.upperBound, termbox.ColorMagenta)
+}
+
+// RSIIndicator renders Wilder's RSI(N) in its own 0-100 sub-pane.
+type RSIIndicator struct {
+	N int
+}
+
+func (r *RSIIndicator) Compute(buckets []*Bucket) []float64 {
+	values := closes(buckets)
+	out := make([]float64, len(values))
+	if len(values) == 0 {
+		return out
+	}
+
+	var avgGain, avgLoss float64
+	for i := 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain, loss := 0.0, 0.0
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		// Wilder's smoothing: a running average that weights the new
+		// sample at 1/N, same shape as an EMA with k=1/N.
+		avgGain = (avgGain*float64(r.N-1) + gain) / float64(r.N)
+		avgLoss = (avgLoss*float64(r.N-1) + loss) / float64(r.N)
+
+		if avgLoss == 0 {
+			out[i] = 100
+			continue
+		}
+		rs := avgGain / avgLoss
+		out[i] = 100 - 100/(1+rs)
+	}
+	out[0] = 50
+	return out
+}
+
+func (r *RSIIndicator) Render(frame Frame, values []float64) {
+	frame.Box(termbox.ColorWhite, termbox.ColorDefault)
+	frame.PrintHeader("RSI", termbox.ColorWhite, termbox.ColorDefault)
+	for line, j := 1, len(values)-1; j >= 0 && line < frame.h; line, j = line+1, j-1 {
+		x := 1 + values[j]/100*float64(frame.w-2)
+		frame.SetCell(int(x), line, '█', termbox.ColorCyan, termbox.ColorDefault)
+	}
+}
+
+// MACDIndicator renders EMA(fast)-EMA(slow) with a signal line as a stacked
+// histogram in its own sub-pane.
+type MACDIndicator struct {
+	Fast, Slow, Signal int
+	signalLine         []float64
+}
+
+func (m *MACDIndicator) Compute(buckets []*Bucket) []float64 {
+	values := closes(buckets)
+	fastEMA := ema(values, m.Fast)
+	slowEMA := ema(values, m.Slow)
+
+	macd := make([]float64, len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+	m.signalLine = ema(macd, m.Signal)
+	return macd
+}
+
+func (m *MACDIndicator) Render(frame Frame, values []float64) {
+	frame.Box(termbox.ColorWhite, termbox.ColorDefault)
+	frame.PrintHeader("MACD", termbox.ColorWhite, termbox.ColorDefault)
+
+	mid := frame.h / 2
+	for line, j := 1, len(values)-1; j >= 0 && line < frame.h; line, j = line+1, j-1 {
+		histogram := values[j] - m.signalLine[j]
+		fg := termbox.ColorGreen
+		if histogram < 0 {
+			fg = termbox.ColorRed
+		}
+		x := mid + int(histogram)
+		if x < 0 {
+			x = 0
+		}
+		if x >= frame.w {
+			x = frame.w - 1
+		}
+		start, stop := mid, x
+		if start > stop {
+			start, stop = stop, start
+		}
+		for i := start; i <= stop; i++ {
+			frame.SetCell(i, line, '█', fg, termbox.ColorDefault)
+		}
+	}
+}
+
+// priceBounds mirrors the min/max logic of BucketRing.Bounds, which supplies
+// DrawCandles' bounds, so overlays line up with the candles they're drawn on
+// top of.
+func priceBounds(buckets []*Bucket) (float64, float64) {
+	lowerBound, upperBound := math.MaxFloat32, 0.0
+	for _, box := range buckets {
+		lowerBound = math.Min(lowerBound, box.Min)
+		upperBound = math.Max(upperBound, box.Max)
+	}
+	if upperBound-lowerBound < 100 {
+		lowerBound -= 50
+		upperBound += 50
+	}
+	return lowerBound, upperBound
+}
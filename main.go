@@ -1,13 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math"
 	"strings"
 	"time"
 
-	ws "github.com/gorilla/websocket"
 	termbox "github.com/nsf/termbox-go"
 	gdax "github.com/preichenberger/go-gdax"
 )
@@ -64,59 +64,6 @@ func shortDuration(d time.Duration) string {
 	return s
 }
 
-var connectionErrCount int = 0
-
-func Connect(out chan gdax.Message) {
-	var wsDialer ws.Dialer
-	wsConn, _, err := wsDialer.Dial("wss://ws-feed.gdax.com", nil)
-	if err != nil {
-		println(err.Error())
-	}
-
-	subscribe := gdax.Message{
-		Type: "subscribe",
-		Channels: []gdax.MessageChannel{
-			{
-				Name: "ticker",
-				ProductIds: []string{
-					fmt.Sprintf("%s-%s", *crypto, *fiat),
-				},
-			},
-		},
-	}
-	if err := wsConn.WriteJSON(subscribe); err != nil {
-		println(err.Error())
-	}
-
-	message := gdax.Message{}
-
-	go func() {
-		for true {
-			if err := wsConn.ReadJSON(&message); err != nil {
-				connectionErrCount++
-				time.Sleep(time.Duration(connectionErrCount*connectionErrCount) * time.Second)
-				Connect(out)
-				break
-			}
-
-			// It seems that the first two messages that come back are always missing
-			// their side and have broken timestamps.  Skip them.
-			if len(message.Side) == 0 {
-				continue
-			}
-
-			connectionErrCount = 0
-			out <- message
-		}
-	}()
-}
-
-func GetMessages() chan gdax.Message {
-	out := make(chan gdax.Message)
-	Connect(out)
-	return out
-}
-
 func GetEvents() chan termbox.Event {
 	out := make(chan termbox.Event)
 	go func() {
@@ -135,6 +82,8 @@ type Bucket struct {
 	Trades   int64
 	Start    time.Time
 	Duration time.Duration
+
+	seq int64 // BucketRing bookkeeping; see ringbuffer.go.
 }
 type Frame struct {
 	x, y, w, h int
@@ -172,7 +121,7 @@ func (f *Frame) PrintHeader(msg string, fg, bg termbox.Attribute) {
 	f.Print(int(math.Ceil(float64(f.w)/2.0-float64(len(msg))/2.0)), 0, fg, bg, msg)
 }
 
-func DrawTrades(tradeFrame Frame, trades []gdax.Message) {
+func DrawTrades(tradeFrame Frame, trades []Trade) {
 	tradeFrame.Box(termbox.ColorWhite, termbox.ColorDefault)
 	for i, j := 1, len(trades)-1; j >= 0 && i < tradeFrame.h; i, j = i+1, j-1 {
 		message := trades[j]
@@ -188,19 +137,10 @@ func DrawTrades(tradeFrame Frame, trades []gdax.Message) {
 	tradeFrame.PrintHeader("Trades", termbox.ColorWhite, termbox.ColorDefault)
 }
 
-func DrawCandles(candleFrame Frame, buckets []*Bucket) {
+func DrawCandles(candleFrame Frame, buckets []*Bucket, lowerBound, upperBound float64) {
 	candleFrame.Box(termbox.ColorWhite, termbox.ColorDefault)
 	candleFrame.PrintHeader("Price", termbox.ColorWhite, termbox.ColorDefault)
 
-	lowerBound, upperBound := math.MaxFloat32, 0.0
-	for _, box := range buckets {
-		lowerBound = math.Min(lowerBound, box.Min)
-		upperBound = math.Max(upperBound, box.Max)
-	}
-	if upperBound-lowerBound < 100 {
-		lowerBound -= 50
-		upperBound += 50
-	}
 	priceSpread := upperBound - lowerBound
 
 	for line, j := 1, len(buckets)-1; j >= 0 && line < candleFrame.h; line, j = line+1, j-1 {
@@ -258,14 +198,23 @@ func DrawVolume(volumeFrame Frame, buckets []*Bucket) {
 	}
 }
 
-func ProcessMessage(message gdax.Message, trades *[]gdax.Message, buckets *[]*Bucket) {
-	*trades = append(*trades, message)
-
-	t := message.Time.Time().Truncate(*candleSize)
-
-	// If there are no buckets, start one.
-	if len(*buckets) == 0 {
-		*buckets = append(*buckets, &Bucket{
+// ProcessMessage feeds a single trade into the ring buffers, mutating the
+// currently-forming bucket or starting a new one as candleSize boundaries
+// are crossed. It returns the bucket that just closed, for the caller to
+// persist, or nil if the current bucket is still accumulating.
+func ProcessMessage(message Trade, trades *TradeRing, buckets *BucketRing) *Bucket {
+	trades.Push(message)
+
+	t := message.Time.Truncate(*candleSize)
+
+	last := buckets.Last()
+	var closed *Bucket
+	if last == nil || !last.Start.Equal(t) {
+		if last != nil {
+			closed = last
+			buckets.CloseLast()
+		}
+		buckets.Push(&Bucket{
 			Open:     message.Price,
 			Close:    message.Price,
 			Start:    t,
@@ -273,49 +222,118 @@ func ProcessMessage(message gdax.Message, trades *[]gdax.Message, buckets *[]*Bu
 			Max:      0.0,
 			Duration: *candleSize,
 		})
-	}
-
-	bucket := (*buckets)[len(*buckets)-1]
-	if (*buckets)[len(*buckets)-1].Start.Equal(t) {
-		bucket.Close = message.Price
+		last = buckets.Last()
 	} else {
-		// Time to start a new bucket.
-		*buckets = append(*buckets, &Bucket{
-			Open:     message.Price,
-			Close:    message.Price,
-			Start:    t,
-			Min:      math.MaxFloat32,
-			Max:      0.0,
-			Duration: *candleSize,
-		})
-		bucket = (*buckets)[len(*buckets)-1]
+		last.Close = message.Price
 	}
-	bucket.Trades++
-	bucket.Max = math.Max(bucket.Max, message.Price)
-	bucket.Min = math.Min(bucket.Min, message.Price)
+	last.Trades++
+	last.Max = math.Max(last.Max, message.Price)
+	last.Min = math.Min(last.Min, message.Price)
+	return closed
 }
 
-func Draw(trades []gdax.Message, buckets []*Bucket) {
+// Draw renders a full frame. When compareRing is non-nil, the candle pane is
+// split in two so the user can watch compareName's candles alongside
+// exchangeName's. trades and buckets are read from their rings once per
+// frame, so render cost stays O(visible width) no matter how long gct has
+// been running.
+func Draw(tradesRing *TradeRing, bucketsRing *BucketRing, exchangeName string, compareRing *BucketRing, compareName string, portfolio *Portfolio, prompt string, book *OrderBook, showDepth bool) {
+	trades := tradesRing.Slice()
+	buckets := bucketsRing.Slice()
+	lowerBound, upperBound := bucketsRing.Bounds()
+
 	width, height := termbox.Size()
 
-	candleWidth := width - (*volumeWidth + *tradeWidth + 3)
+	candleWidth := width - (*volumeWidth + *tradeWidth + *portfolioWidth + 4)
+
+	const subPaneHeight = 6
+	subPanes := 0
+	if _, ok := activeIndicators["rsi"]; ok {
+		subPanes++
+	}
+	if _, ok := activeIndicators["macd"]; ok {
+		subPanes++
+	}
+	mainHeight := height - 2 - subPanes*subPaneHeight
 
-	volumeFrame := Frame{0, 1, *volumeWidth, height - 2}
-	candleFrame := Frame{*volumeWidth + 1, 1, candleWidth, height - 2}
+	volumeFrame := Frame{0, 1, *volumeWidth, mainHeight}
+	candleFrame := Frame{*volumeWidth + 1, 1, candleWidth, mainHeight}
 	tradeFrame := Frame{*volumeWidth + candleWidth + 2, 1, *tradeWidth, height - 2}
+	portfolioFrame := Frame{*volumeWidth + candleWidth + *tradeWidth + 3, 1, *portfolioWidth, height - 2}
+
+	subFrameY := 1 + mainHeight + 1
+	subFrameWidth := *volumeWidth + candleWidth + 1
 
 	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 
-	printf_tb(0, 0, termbox.ColorWhite, termbox.ColorDefault,
-		"Crypto: %s   Fiat: %s   Exchange: GDAX   Candle Size: %s", *crypto, *fiat, shortDuration(*candleSize))
+	header := fmt.Sprintf("Crypto: %s   Fiat: %s   Exchange: %s   Candle Size: %s",
+		*crypto, *fiat, exchangeName, shortDuration(*candleSize))
+	if compareRing != nil {
+		header += fmt.Sprintf("   vs: %s", compareName)
+	}
+	printf_tb(0, 0, termbox.ColorWhite, termbox.ColorDefault, "%s", header)
 
-	DrawTrades(tradeFrame, trades)
-	DrawCandles(candleFrame, buckets)
+	if showDepth && book != nil {
+		DrawOrderBook(tradeFrame, book)
+	} else {
+		DrawTrades(tradeFrame, trades)
+	}
+	overlayFrame := candleFrame
+	if compareRing != nil {
+		compareBuckets := compareRing.Slice()
+		compareLower, compareUpper := compareRing.Bounds()
+
+		leftWidth := candleWidth / 2
+		leftFrame := Frame{candleFrame.x, candleFrame.y, leftWidth, candleFrame.h}
+		rightFrame := Frame{candleFrame.x + leftWidth + 1, candleFrame.y, candleWidth - leftWidth - 1, candleFrame.h}
+		DrawCandles(leftFrame, buckets, lowerBound, upperBound)
+		DrawCandles(rightFrame, compareBuckets, compareLower, compareUpper)
+		overlayFrame = leftFrame
+	} else {
+		DrawCandles(candleFrame, buckets, lowerBound, upperBound)
+	}
 	DrawVolume(volumeFrame, buckets)
+	drawOverlayIndicators(overlayFrame, buckets)
+	drawSubPaneIndicators(buckets, subFrameY, subFrameWidth, subPaneHeight)
+
+	currentPrice := 0.0
+	if len(buckets) > 0 {
+		currentPrice = buckets[len(buckets)-1].Close
+	}
+	DrawPortfolio(portfolioFrame, portfolio, currentPrice)
+
+	if prompt != "" {
+		print_tb(0, height-1, termbox.ColorWhite, termbox.ColorDefault, prompt)
+	}
 
 	termbox.Flush()
 }
 
+// drawOverlayIndicators renders the active EMA/SMA/Bollinger indicators on
+// top of the candle pane.
+func drawOverlayIndicators(candleFrame Frame, buckets []*Bucket) {
+	for name, ind := range activeIndicators {
+		if name == "rsi" || name == "macd" {
+			continue
+		}
+		ind.Render(candleFrame, ind.Compute(buckets))
+	}
+}
+
+// drawSubPaneIndicators renders the active RSI/MACD indicators, each in its
+// own strip stacked below the candle and volume panes.
+func drawSubPaneIndicators(buckets []*Bucket, y, width, height int) {
+	for _, name := range []string{"rsi", "macd"} {
+		ind, ok := activeIndicators[name]
+		if !ok {
+			continue
+		}
+		frame := Frame{0, y, width, height}
+		ind.Render(frame, ind.Compute(buckets))
+		y += height + 1
+	}
+}
+
 func main() {
 	flag.Parse()
 
@@ -326,27 +344,145 @@ func main() {
 	defer termbox.Close()
 	termbox.SetInputMode(termbox.InputEsc)
 
-	messages := GetMessages()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	product := fmt.Sprintf("%s-%s", *crypto, *fiat)
+
+	feed, err := NewExchangeFeed(ctx, *exchangeName)
+	if err != nil {
+		panic(err)
+	}
+	if err := ValidateProduct(feed, product); err != nil {
+		panic(err)
+	}
+	messages := GetMessages(feed, product)
+
+	var book *OrderBook
+	if obFeed, ok := feed.(OrderBookFeed); ok {
+		book = obFeed.OrderBook(product)
+	}
+	showDepth := false
+
+	// Ring buffers are sized to max(terminal width, --history_buckets), so
+	// memory and per-frame render cost stay bounded no matter how long gct
+	// runs.
+	width, _ := termbox.Size()
+	ringCapacity := *historyBucketsFlag
+	if width > ringCapacity {
+		ringCapacity = width
+	}
+
+	var compareMessages chan Trade
+	var compareBuckets *BucketRing
+	var discardTrades *TradeRing
+	if *compare != "" {
+		compareFeed, err := NewExchangeFeed(ctx, *compare)
+		if err != nil {
+			panic(err)
+		}
+		if err := ValidateProduct(compareFeed, product); err != nil {
+			panic(err)
+		}
+		compareMessages = GetMessages(compareFeed, product)
+		compareBuckets = NewBucketRing(ringCapacity)
+		discardTrades = NewTradeRing(ringCapacity)
+	}
+
 	events := GetEvents()
-	trades := []gdax.Message{}
-	buckets := []*Bucket{}
+	trades := NewTradeRing(ringCapacity)
+	buckets := NewBucketRing(ringCapacity)
+
+	for name, ind := range parseIndicators(*indicatorsFlag) {
+		activeIndicators[name] = ind
+	}
+
+	portfolio, err := NewPortfolio(*portfolioPath)
+	if err != nil {
+		panic(err)
+	}
+	prompt := &orderPrompt{}
 
-	Draw(trades, buckets)
+	candleStore, err := NewStore(*store, *storePath)
+	if err != nil {
+		panic(err)
+	}
+	var initialBuckets []*Bucket
+	if candleStore != nil {
+		defer candleStore.Close()
+
+		saved, err := candleStore.Load(product)
+		if err != nil {
+			panic(err)
+		}
+		initialBuckets = saved
+	}
+
+	if *history > 0 {
+		backfilled, err := Backfill(gdax.NewClient("", "", ""), product, *candleSize, *history)
+		if err != nil {
+			println(err.Error())
+		} else {
+			initialBuckets = mergeBuckets(initialBuckets, backfilled)
+		}
+	}
+	for i, b := range initialBuckets {
+		buckets.Push(b)
+		if i < len(initialBuckets)-1 {
+			buckets.CloseLast()
+		}
+	}
+
+	Draw(trades, buckets, feed.Name(), nil, "", portfolio, "", book, showDepth)
 
 loop:
 	for {
 		select {
 		case message := <-messages:
-			ProcessMessage(message, &trades, &buckets)
-			Draw(trades, buckets)
+			closed := ProcessMessage(message, trades, buckets)
+			if candleStore != nil && closed != nil {
+				if err := candleStore.Save(product, closed); err != nil {
+					println(err.Error())
+				}
+			}
+			portfolio.OnTrade(message)
+			Draw(trades, buckets, feed.Name(), compareBuckets, *compare, portfolio, prompt.prompt(), book, showDepth)
+		case message := <-compareMessages:
+			ProcessMessage(message, discardTrades, compareBuckets)
+			Draw(trades, buckets, feed.Name(), compareBuckets, *compare, portfolio, prompt.prompt(), book, showDepth)
 		case ev := <-events:
 			switch ev.Type {
 			case termbox.EventKey:
-				if ev.Key == termbox.KeyEsc {
+				if ev.Key == termbox.KeyEsc && !prompt.active() {
 					break loop
 				}
+				switch {
+				case prompt.active():
+					prompt.handleKey(ev, portfolio)
+				case ev.Ch == 'e':
+					toggleIndicator("ema")
+				case ev.Ch == 'b':
+					toggleIndicator("bb")
+				case ev.Ch == 'r':
+					toggleIndicator("rsi")
+				case ev.Ch == 'm':
+					toggleIndicator("macd")
+				case ev.Ch == 'd':
+					showDepth = !showDepth
+				// Uppercase keys drive paper trading, since lowercase
+				// b/e/r/m are already taken by indicator toggles.
+				case ev.Ch == 'B':
+					prompt.start("buy")
+				case ev.Ch == 'S':
+					prompt.start("sell")
+				case ev.Ch == 'L':
+					prompt.startLimit()
+				case ev.Ch == 'C':
+					portfolio.CancelLast()
+				}
+				Draw(trades, buckets, feed.Name(), compareBuckets, *compare, portfolio, prompt.prompt(), book, showDepth)
 			case termbox.EventResize:
-				Draw(trades, buckets)
+				Draw(trades, buckets, feed.Name(), compareBuckets, *compare, portfolio, prompt.prompt(), book, showDepth)
 			}
 		}
 	}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// OrderBookFeed is implemented by exchange feeds that can maintain a live
+// level-2 order book, in addition to the plain ExchangeFeed trade stream.
+// Coinbase is the only one that does today.
+type OrderBookFeed interface {
+	ExchangeFeed
+
+	// OrderBook returns the book being maintained for product, creating it
+	// if this is the first caller to ask for it.
+	OrderBook(product string) *OrderBook
+}
+
+// PriceLevel is a single price and its resting size in the book.
+type PriceLevel struct {
+	Price float64
+	Size  float64
+}
+
+// OrderBook is a sorted bid/ask book kept current via incremental
+// price_level updates from an exchange's level2 channel. A zero size at a
+// price level means it's been fully consumed and should be removed.
+type OrderBook struct {
+	mu   sync.Mutex
+	bids map[float64]float64
+	asks map[float64]float64
+}
+
+// NewOrderBook returns an empty book ready for incremental updates.
+func NewOrderBook() *OrderBook {
+	return &OrderBook{
+		bids: map[float64]float64{},
+		asks: map[float64]float64{},
+	}
+}
+
+// Update applies a single incremental price level change.
+func (b *OrderBook) Update(side string, price, size float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	levels := b.asks
+	if side == "buy" {
+		levels = b.bids
+	}
+	if size == 0 {
+		delete(levels, price)
+	} else {
+		levels[price] = size
+	}
+}
+
+// TopBids returns the n highest-priced bid levels, best first.
+func (b *OrderBook) TopBids(n int) []PriceLevel {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return topLevels(b.bids, n, true)
+}
+
+// TopAsks returns the n lowest-priced ask levels, best first.
+func (b *OrderBook) TopAsks(n int) []PriceLevel {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return topLevels(b.asks, n, false)
+}
+
+func topLevels(levels map[float64]float64, n int, descending bool) []PriceLevel {
+	out := make([]PriceLevel, 0, len(levels))
+	for price, size := range levels {
+		out = append(out, PriceLevel{Price: price, Size: size})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if descending {
+			return out[i].Price > out[j].Price
+		}
+		return out[i].Price < out[j].Price
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// BestBidAsk returns the best bid and ask price. A side with no levels
+// reports 0 for its price.
+func (b *OrderBook) BestBidAsk() (float64, float64) {
+	bids := b.TopBids(1)
+	asks := b.TopAsks(1)
+	var bestBid, bestAsk float64
+	if len(bids) > 0 {
+		bestBid = bids[0].Price
+	}
+	if len(asks) > 0 {
+		bestAsk = asks[0].Price
+	}
+	return bestBid, bestAsk
+}
+
+// MidPrice is the simple average of the best bid and ask.
+func (b *OrderBook) MidPrice() float64 {
+	bid, ask := b.BestBidAsk()
+	if bid == 0 || ask == 0 {
+		return 0
+	}
+	return (bid + ask) / 2
+}
+
+// Spread is the best ask minus the best bid.
+func (b *OrderBook) Spread() float64 {
+	bid, ask := b.BestBidAsk()
+	if bid == 0 || ask == 0 {
+		return 0
+	}
+	return ask - bid
+}
+
+// MicroPrice weights the best bid/ask by the size resting on the *other*
+// side, so it leans toward whichever side is about to get eaten through.
+func (b *OrderBook) MicroPrice() float64 {
+	bids := b.TopBids(1)
+	asks := b.TopAsks(1)
+	if len(bids) == 0 || len(asks) == 0 {
+		return 0
+	}
+	bid, ask := bids[0], asks[0]
+	totalSize := bid.Size + ask.Size
+	if totalSize == 0 {
+		return 0
+	}
+	return (bid.Price*ask.Size + ask.Price*bid.Size) / totalSize
+}
+
+// DrawOrderBook renders the top of book as horizontal depth bars, mirroring
+// DrawVolume's style, with mid-price/spread/micro-price above.
+func DrawOrderBook(frame Frame, book *OrderBook) {
+	frame.Box(termbox.ColorWhite, termbox.ColorDefault)
+	frame.PrintHeader("Depth", termbox.ColorWhite, termbox.ColorDefault)
+
+	depth := (frame.h - 2) / 2
+	if depth < 1 {
+		depth = 1
+	}
+	bids := book.TopBids(depth)
+	asks := book.TopAsks(depth)
+
+	frame.Printf(1, 1, termbox.ColorWhite, termbox.ColorDefault,
+		"mid %.2f spd %.2f micro %.2f", book.MidPrice(), book.Spread(), book.MicroPrice())
+
+	maxSize := 0.0
+	for _, l := range bids {
+		maxSize = math.Max(maxSize, l.Size)
+	}
+	for _, l := range asks {
+		maxSize = math.Max(maxSize, l.Size)
+	}
+	if maxSize == 0 {
+		maxSize = 1
+	}
+
+	line := 2
+	for i := len(asks) - 1; i >= 0 && line < frame.h; i, line = i-1, line+1 {
+		drawDepthBar(frame, line, asks[i], maxSize, termbox.ColorRed)
+	}
+	for _, l := range bids {
+		if line >= frame.h {
+			break
+		}
+		drawDepthBar(frame, line, l, maxSize, termbox.ColorGreen)
+		line++
+	}
+}
+
+func drawDepthBar(frame Frame, line int, level PriceLevel, maxSize float64, fg termbox.Attribute) {
+	label := fmt.Sprintf("%.2f %.3f", level.Price, level.Size)
+	frame.Print(1, line, fg, termbox.ColorDefault, label)
+
+	barStart := len(label) + 2
+	barWidth := int(level.Size / maxSize * float64(frame.w-barStart-1))
+	for i := 0; i < barWidth; i++ {
+		frame.SetCell(barStart+i, line, '─', fg, termbox.ColorDefault)
+	}
+}
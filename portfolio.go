@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+var portfolioWidth = flag.Int("portfolio_width", 20, "Width of the portfolio pane.")
+
+var portfolioPath = flag.String(
+	"portfolio_path", "gct_portfolio.json", "Path to persist paper-trading positions across runs.")
+
+var slippageBps = flag.Int(
+	"slippage_bps", 5, "Simulated slippage applied to market order fills, in basis points.")
+
+// Order is a resting paper-trading order. LimitPrice is zero for a market
+// order, which fills at the next streamed trade (plus slippage).
+type Order struct {
+	Side       string
+	Size       float64
+	LimitPrice float64
+	Placed     time.Time
+}
+
+// Portfolio simulates fills against the live trade stream and tracks
+// position, average entry, and P&L. It's read-only-safe to render from
+// multiple goroutines as long as callers serialize mutation, which gct does
+// by only ever touching it from the main select loop.
+type Portfolio struct {
+	Position    float64
+	AvgEntry    float64
+	RealizedPnL float64
+	OpenOrders  []*Order
+
+	path string
+}
+
+// NewPortfolio loads a Portfolio from path if it exists, or returns an empty
+// one otherwise.
+func NewPortfolio(path string) (*Portfolio, error) {
+	p := &Portfolio{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	p.path = path
+	return p, nil
+}
+
+func (p *Portfolio) save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, data, 0644)
+}
+
+// PlaceMarket queues a market order; it fills on the next OnTrade call. Sizes
+// that aren't strictly positive are rejected, since a zero or negative size
+// corrupts Position/AvgEntry in fill rather than doing nothing.
+func (p *Portfolio) PlaceMarket(side string, size float64) error {
+	if size <= 0 {
+		return fmt.Errorf("order size must be positive, got %v", size)
+	}
+	p.OpenOrders = append(p.OpenOrders, &Order{Side: side, Size: size, Placed: time.Now()})
+	p.save()
+	return nil
+}
+
+// PlaceLimit queues a limit order; it fills once a streamed trade crosses
+// limitPrice. Sizes that aren't strictly positive are rejected; see
+// PlaceMarket.
+func (p *Portfolio) PlaceLimit(side string, size, limitPrice float64) error {
+	if size <= 0 {
+		return fmt.Errorf("order size must be positive, got %v", size)
+	}
+	p.OpenOrders = append(p.OpenOrders, &Order{Side: side, Size: size, LimitPrice: limitPrice, Placed: time.Now()})
+	p.save()
+	return nil
+}
+
+// CancelLast removes the most recently placed open order, if any.
+func (p *Portfolio) CancelLast() {
+	if len(p.OpenOrders) == 0 {
+		return
+	}
+	p.OpenOrders = p.OpenOrders[:len(p.OpenOrders)-1]
+	p.save()
+}
+
+// OnTrade checks every open order against the latest streamed trade, filling
+// any that cross (limit orders) or that are still market orders (which
+// always fill on the next tick, with slippage).
+func (p *Portfolio) OnTrade(trade Trade) {
+	remaining := p.OpenOrders[:0]
+	for _, o := range p.OpenOrders {
+		switch {
+		case o.LimitPrice == 0:
+			p.fill(o.Side, o.Size, withSlippage(trade.Price, o.Side))
+		case o.Side == "buy" && trade.Price <= o.LimitPrice:
+			p.fill(o.Side, o.Size, o.LimitPrice)
+		case o.Side == "sell" && trade.Price >= o.LimitPrice:
+			p.fill(o.Side, o.Size, o.LimitPrice)
+		default:
+			remaining = append(remaining, o)
+		}
+	}
+	p.OpenOrders = remaining
+	p.save()
+}
+
+// withSlippage nudges a market fill price against the trader: up for buys,
+// down for sells.
+func withSlippage(price float64, side string) float64 {
+	adjustment := price * float64(*slippageBps) / 10000
+	if side == "buy" {
+		return price + adjustment
+	}
+	return price - adjustment
+}
+
+// fill applies a single execution to the position, updating average entry
+// and realized P&L for whatever portion closes an existing position.
+func (p *Portfolio) fill(side string, size, price float64) {
+	signedSize := size
+	if side == "sell" {
+		signedSize = -size
+	}
+
+	if p.Position == 0 || sameSign(p.Position, signedSize) {
+		newPosition := p.Position + signedSize
+		p.AvgEntry = (p.AvgEntry*math.Abs(p.Position) + price*size) / math.Abs(newPosition)
+		p.Position = newPosition
+		return
+	}
+
+	closingSize := math.Min(size, math.Abs(p.Position))
+	pnlPerUnit := price - p.AvgEntry
+	if p.Position < 0 {
+		pnlPerUnit = p.AvgEntry - price
+	}
+	p.RealizedPnL += pnlPerUnit * closingSize
+	p.Position += signedSize
+
+	leftover := size - closingSize
+	if leftover > 0 {
+		// The fill was bigger than the open position, so it flipped sides;
+		// whatever's left opens a fresh position at this fill's price.
+		p.AvgEntry = price
+	} else if p.Position == 0 {
+		p.AvgEntry = 0
+	}
+}
+
+func sameSign(a, b float64) bool {
+	return (a >= 0) == (b >= 0)
+}
+
+// UnrealizedPnL values the open position at currentPrice.
+func (p *Portfolio) UnrealizedPnL(currentPrice float64) float64 {
+	if p.Position == 0 {
+		return 0
+	}
+	if p.Position > 0 {
+		return (currentPrice - p.AvgEntry) * p.Position
+	}
+	return (p.AvgEntry - currentPrice) * -p.Position
+}
+
+// DrawPortfolio renders open orders, average entry, and P&L in frame.
+func DrawPortfolio(frame Frame, p *Portfolio, currentPrice float64) {
+	frame.Box(termbox.ColorWhite, termbox.ColorDefault)
+	frame.PrintHeader("Portfolio", termbox.ColorWhite, termbox.ColorDefault)
+
+	line := 1
+	frame.Printf(1, line, termbox.ColorWhite, termbox.ColorDefault, "Pos:   %.4f", p.Position)
+	line++
+	frame.Printf(1, line, termbox.ColorWhite, termbox.ColorDefault, "Entry: %.2f", p.AvgEntry)
+	line++
+
+	unrealizedFg := termbox.ColorGreen
+	unrealized := p.UnrealizedPnL(currentPrice)
+	if unrealized < 0 {
+		unrealizedFg = termbox.ColorRed
+	}
+	frame.Printf(1, line, unrealizedFg, termbox.ColorDefault, "uPnL:  %.2f", unrealized)
+	line++
+
+	realizedFg := termbox.ColorGreen
+	if p.RealizedPnL < 0 {
+		realizedFg = termbox.ColorRed
+	}
+	frame.Printf(1, line, realizedFg, termbox.ColorDefault, "rPnL:  %.2f", p.RealizedPnL)
+	line += 2
+
+	frame.Print(1, line, termbox.ColorWhite, termbox.ColorDefault, "Open orders:")
+	line++
+	for i, o := range p.OpenOrders {
+		if line >= frame.h {
+			break
+		}
+		priceLabel := "mkt"
+		if o.LimitPrice != 0 {
+			priceLabel = fmt.Sprintf("%.2f", o.LimitPrice)
+		}
+		fg := termbox.ColorGreen
+		if o.Side == "sell" {
+			fg = termbox.ColorRed
+		}
+		frame.Printf(1, line, fg, termbox.ColorDefault, "%d %-4s %.4f @ %s", i, o.Side, o.Size, priceLabel)
+		line++
+	}
+}
+
+// orderPrompt drives the B/S/L keybindings, collecting a size (and for
+// limit orders, a side and a price) on the prompt line before placing an
+// order. C cancels directly through Portfolio.CancelLast, without a prompt.
+type orderPrompt struct {
+	side   string
+	stage  string // "", "side", "limit_price", "size"
+	limit  float64
+	buffer string
+}
+
+// start begins a market order for side ("buy"/"sell"), prompting for size.
+func (p *orderPrompt) start(side string) {
+	p.side = side
+	p.limit = 0
+	p.buffer = ""
+	p.stage = "size"
+}
+
+// startLimit begins a limit order, first prompting for which side to trade.
+func (p *orderPrompt) startLimit() {
+	p.side = ""
+	p.limit = 0
+	p.buffer = ""
+	p.stage = "side"
+}
+
+func (p *orderPrompt) active() bool {
+	return p.stage != ""
+}
+
+// handleKey feeds a keypress into the in-progress prompt, placing the order
+// against portfolio once the size is submitted with Enter.
+func (p *orderPrompt) handleKey(ev termbox.Event, portfolio *Portfolio) {
+	if p.stage == "side" {
+		switch ev.Ch {
+		case 'b':
+			p.side = "buy"
+			p.stage = "limit_price"
+		case 's':
+			p.side = "sell"
+			p.stage = "limit_price"
+		case 0:
+			if ev.Key == termbox.KeyEsc {
+				p.stage = ""
+			}
+		}
+		return
+	}
+
+	switch ev.Key {
+	case termbox.KeyEnter:
+		value, err := strconv.ParseFloat(p.buffer, 64)
+		if err != nil {
+			p.stage = ""
+			return
+		}
+		switch p.stage {
+		case "limit_price":
+			p.limit = value
+			p.stage = "size"
+			p.buffer = ""
+		case "size":
+			if value <= 0 {
+				p.stage = ""
+				return
+			}
+			if p.limit != 0 {
+				portfolio.PlaceLimit(p.side, value, p.limit)
+			} else {
+				portfolio.PlaceMarket(p.side, value)
+			}
+			p.stage = ""
+		}
+	case termbox.KeyEsc:
+		p.stage = ""
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if len(p.buffer) > 0 {
+			p.buffer = p.buffer[:len(p.buffer)-1]
+		}
+	default:
+		if ev.Ch == '.' || ev.Ch == '-' || (ev.Ch >= '0' && ev.Ch <= '9') {
+			p.buffer += string(ev.Ch)
+		}
+	}
+}
+
+// prompt returns the text to render on the prompt line, or "" if no order is
+// in progress.
+func (p *orderPrompt) prompt() string {
+	switch p.stage {
+	case "side":
+		return "limit order side (b/s)?"
+	case "limit_price":
+		return fmt.Sprintf("%s limit price: %s", strings.ToUpper(p.side), p.buffer)
+	case "size":
+		return fmt.Sprintf("%s size: %s", strings.ToUpper(p.side), p.buffer)
+	default:
+		return ""
+	}
+}
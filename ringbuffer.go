@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"math"
+)
+
+var historyBucketsFlag = flag.Int(
+	"history_buckets", 500,
+	"Minimum number of candles to keep in memory; the ring buffer is sized to max(terminal width, this).")
+
+// BucketRing is a fixed-capacity ring buffer of *Bucket. Once full, pushing
+// a new bucket evicts the oldest one, keeping memory and per-frame render
+// cost bounded regardless of how long gct has been running.
+type BucketRing struct {
+	buf     []*Bucket
+	start   int
+	length  int
+	nextSeq int64
+	bounds  *priceBoundsTracker
+}
+
+// NewBucketRing returns an empty ring with room for capacity buckets.
+func NewBucketRing(capacity int) *BucketRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &BucketRing{
+		buf:    make([]*Bucket, capacity),
+		bounds: newPriceBoundsTracker(),
+	}
+}
+
+func (r *BucketRing) Len() int { return r.length }
+
+// Last returns the most recently pushed bucket, or nil if the ring is
+// empty.
+func (r *BucketRing) Last() *Bucket {
+	if r.length == 0 {
+		return nil
+	}
+	return r.buf[(r.start+r.length-1)%len(r.buf)]
+}
+
+// Push appends b, evicting and closing out the oldest bucket's contribution
+// to the rolling price bounds if the ring is already full.
+func (r *BucketRing) Push(b *Bucket) {
+	b.seq = r.nextSeq
+	r.nextSeq++
+
+	idx := (r.start + r.length) % len(r.buf)
+	if r.length == len(r.buf) {
+		r.bounds.evict(r.buf[idx].seq)
+		r.start = (r.start + 1) % len(r.buf)
+	} else {
+		r.length++
+	}
+	r.buf[idx] = b
+}
+
+// CloseLast folds the current last bucket's min/max into the rolling bounds
+// tracker, since it won't be mutated further once a new bucket starts.
+func (r *BucketRing) CloseLast() {
+	if last := r.Last(); last != nil {
+		r.bounds.push(last.seq, last.Min, last.Max)
+	}
+}
+
+// Bounds returns the price range to draw against: the rolling bounds over
+// every closed bucket still in the ring, widened by the currently-forming
+// last bucket (which hasn't been folded in yet).
+func (r *BucketRing) Bounds() (float64, float64) {
+	lower, upper := r.bounds.Bounds()
+	if last := r.Last(); last != nil {
+		lower = math.Min(lower, last.Min)
+		upper = math.Max(upper, last.Max)
+	}
+	if upper-lower < 100 {
+		lower -= 50
+		upper += 50
+	}
+	return lower, upper
+}
+
+// Slice returns every bucket currently in the ring, oldest first. The
+// result is a fresh copy sized to the ring's (bounded) length, so callers
+// that render it stay O(visible width) per frame.
+func (r *BucketRing) Slice() []*Bucket {
+	out := make([]*Bucket, r.length)
+	for i := 0; i < r.length; i++ {
+		out[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return out
+}
+
+// TradeRing is a fixed-capacity ring buffer of Trade, analogous to
+// BucketRing but for the trade tape.
+type TradeRing struct {
+	buf    []Trade
+	start  int
+	length int
+}
+
+func NewTradeRing(capacity int) *TradeRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &TradeRing{buf: make([]Trade, capacity)}
+}
+
+func (r *TradeRing) Push(t Trade) {
+	idx := (r.start + r.length) % len(r.buf)
+	if r.length == len(r.buf) {
+		r.start = (r.start + 1) % len(r.buf)
+	} else {
+		r.length++
+	}
+	r.buf[idx] = t
+}
+
+func (r *TradeRing) Len() int { return r.length }
+
+func (r *TradeRing) Slice() []Trade {
+	out := make([]Trade, r.length)
+	for i := 0; i < r.length; i++ {
+		out[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return out
+}
+
+// priceBoundsTracker maintains a sliding-window min and max over closed
+// buckets using two monotonic deques, so both queries and the amortized
+// cost of each push/evict are O(1) regardless of window size.
+type priceBoundsTracker struct {
+	minDeque []boundEntry // increasing value front-to-back; front is the min
+	maxDeque []boundEntry // decreasing value front-to-back; front is the max
+}
+
+type boundEntry struct {
+	seq   int64
+	value float64
+}
+
+func newPriceBoundsTracker() *priceBoundsTracker {
+	return &priceBoundsTracker{}
+}
+
+// push records a newly-closed bucket's min and max.
+func (t *priceBoundsTracker) push(seq int64, min, max float64) {
+	for len(t.minDeque) > 0 && t.minDeque[len(t.minDeque)-1].value >= min {
+		t.minDeque = t.minDeque[:len(t.minDeque)-1]
+	}
+	t.minDeque = append(t.minDeque, boundEntry{seq, min})
+
+	for len(t.maxDeque) > 0 && t.maxDeque[len(t.maxDeque)-1].value <= max {
+		t.maxDeque = t.maxDeque[:len(t.maxDeque)-1]
+	}
+	t.maxDeque = append(t.maxDeque, boundEntry{seq, max})
+}
+
+// evict drops any entries at or before seq, i.e. the bucket that just fell
+// out of the ring's window.
+func (t *priceBoundsTracker) evict(seq int64) {
+	for len(t.minDeque) > 0 && t.minDeque[0].seq <= seq {
+		t.minDeque = t.minDeque[1:]
+	}
+	for len(t.maxDeque) > 0 && t.maxDeque[0].seq <= seq {
+		t.maxDeque = t.maxDeque[1:]
+	}
+}
+
+// Bounds returns the current window's min and max, or (MaxFloat32, 0) if
+// nothing has been pushed yet (matching the sentinel DrawCandles used to
+// use before the window had any data).
+func (t *priceBoundsTracker) Bounds() (float64, float64) {
+	lower, upper := math.MaxFloat32, 0.0
+	if len(t.minDeque) > 0 {
+		lower = t.minDeque[0].value
+	}
+	if len(t.maxDeque) > 0 {
+		upper = t.maxDeque[0].value
+	}
+	return lower, upper
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBucketRingBoundsFoldsClosedBuckets verifies that Bounds() reflects the
+// min/max of every bucket that's been closed out via CloseLast, not just the
+// most recently pushed one.
+func TestBucketRingBoundsFoldsClosedBuckets(t *testing.T) {
+	ring := NewBucketRing(10)
+	start := time.Now()
+	minMax := [][2]float64{{50, 150}, {95, 105}, {96, 106}, {97, 107}, {98, 108}}
+	for i, mm := range minMax {
+		ring.Push(&Bucket{Min: mm[0], Max: mm[1], Start: start.Add(time.Duration(i) * time.Minute)})
+		ring.CloseLast()
+	}
+
+	lower, upper := ring.Bounds()
+	if lower != 50 || upper != 150 {
+		t.Errorf("Bounds() = (%v, %v), want (50, 150)", lower, upper)
+	}
+}
+
+// TestBucketRingBoundsEvictsOldBuckets verifies that once a bucket falls out
+// of the ring, its contribution to the rolling bounds is evicted along with
+// it rather than lingering forever.
+func TestBucketRingBoundsEvictsOldBuckets(t *testing.T) {
+	ring := NewBucketRing(3)
+	start := time.Now()
+	minMax := [][2]float64{{0, 500}, {190, 310}, {191, 311}, {192, 312}, {193, 313}}
+	for i, mm := range minMax {
+		ring.Push(&Bucket{Min: mm[0], Max: mm[1], Start: start.Add(time.Duration(i) * time.Minute)})
+		ring.CloseLast()
+	}
+
+	// The extreme {0, 500} bucket was evicted two pushes ago (capacity 3),
+	// so the rolling bounds should now reflect only the three buckets left
+	// in the ring.
+	lower, upper := ring.Bounds()
+	if lower != 191 || upper != 313 {
+		t.Errorf("Bounds() = (%v, %v), want (191, 313)", lower, upper)
+	}
+}
+
+// BenchmarkBucketRingPush demonstrates that pushing into a full ring costs
+// the same regardless of how many buckets have already streamed through it:
+// b.N grows across runs, but ns/op should stay essentially flat.
+func BenchmarkBucketRingPush(b *testing.B) {
+	ring := NewBucketRing(500)
+	start := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.Push(&Bucket{
+			Open:  1.0,
+			Close: 1.0,
+			Min:   1.0,
+			Max:   1.0,
+			Start: start.Add(time.Duration(i) * time.Minute),
+		})
+		ring.CloseLast()
+	}
+}
+
+// BenchmarkBucketRingBounds demonstrates that reading the rolling price
+// bounds is O(1) regardless of how many buckets have cycled through the
+// ring, since priceBoundsTracker is backed by monotonic deques rather than
+// a rescan.
+func BenchmarkBucketRingBounds(b *testing.B) {
+	ring := NewBucketRing(500)
+	start := time.Now()
+	for i := 0; i < 10000; i++ {
+		ring.Push(&Bucket{
+			Open:  float64(i % 100),
+			Close: float64(i % 100),
+			Min:   float64(i % 100),
+			Max:   float64((i%100)+1),
+			Start: start.Add(time.Duration(i) * time.Minute),
+		})
+		ring.CloseLast()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.Bounds()
+	}
+}
+
+// BenchmarkProcessMessageSustainedSession feeds a long run of trades through
+// ProcessMessage to show that per-tick cost doesn't grow with session
+// length once storage is ring-bounded: ns/op should stay flat whether b.N
+// is 1,000 or 1,000,000.
+func BenchmarkProcessMessageSustainedSession(b *testing.B) {
+	trades := NewTradeRing(500)
+	buckets := NewBucketRing(500)
+	start := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ProcessMessage(Trade{
+			Side:  "buy",
+			Price: float64(1000 + i%50),
+			Time:  start.Add(time.Duration(i) * time.Second),
+		}, trades, buckets)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	gdax "github.com/preichenberger/go-gdax"
+)
+
+var store = flag.String(
+	"store", "", "Persistence backend for candles: \"\" (disabled), \"sqlite\", or \"json\".")
+
+var storePath = flag.String(
+	"store_path", "gct.db", "Path to the store file (sqlite database or JSON flat file).")
+
+var history = flag.Duration(
+	"history", 0, "How far back to backfill candles from GDAX's REST API on startup.")
+
+// Store persists closed candles and reloads them on startup.
+type Store interface {
+	// Save appends a closed bucket to the store.
+	Save(product string, bucket *Bucket) error
+
+	// Load returns every bucket previously saved for product, oldest first.
+	Load(product string) ([]*Bucket, error)
+
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// NewStore builds the Store named by kind, or nil if kind is "".
+func NewStore(kind, path string) (Store, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case "sqlite":
+		return newSQLiteStore(path)
+	case "json":
+		return newJSONStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store kind %q", kind)
+	}
+}
+
+// sqliteStore is a Store backed by a single SQLite database file.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS buckets (
+		product  TEXT NOT NULL,
+		start    INTEGER NOT NULL,
+		duration INTEGER NOT NULL,
+		open     REAL NOT NULL,
+		close    REAL NOT NULL,
+		min      REAL NOT NULL,
+		max      REAL NOT NULL,
+		trades   INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Save(product string, bucket *Bucket) error {
+	_, err := s.db.Exec(
+		`INSERT INTO buckets (product, start, duration, open, close, min, max, trades)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		product, bucket.Start.Unix(), int64(bucket.Duration), bucket.Open, bucket.Close,
+		bucket.Min, bucket.Max, bucket.Trades)
+	return err
+}
+
+func (s *sqliteStore) Load(product string) ([]*Bucket, error) {
+	rows, err := s.db.Query(
+		`SELECT start, duration, open, close, min, max, trades FROM buckets
+		 WHERE product = ? ORDER BY start ASC`, product)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []*Bucket
+	for rows.Next() {
+		var startUnix, duration, trades int64
+		bucket := &Bucket{}
+		if err := rows.Scan(&startUnix, &duration, &bucket.Open, &bucket.Close,
+			&bucket.Min, &bucket.Max, &trades); err != nil {
+			return nil, err
+		}
+		bucket.Start = time.Unix(startUnix, 0)
+		bucket.Duration = time.Duration(duration)
+		bucket.Trades = trades
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// jsonStore is a Store backed by a flat file of newline-delimited JSON
+// records, one per closed bucket. It's meant for casual/local use where
+// pulling in sqlite isn't worth it.
+type jsonStore struct {
+	path string
+}
+
+type jsonBucketRecord struct {
+	Product  string        `json:"product"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Open     float64       `json:"open"`
+	Close    float64       `json:"close"`
+	Min      float64       `json:"min"`
+	Max      float64       `json:"max"`
+	Trades   int64         `json:"trades"`
+}
+
+func newJSONStore(path string) (*jsonStore, error) {
+	return &jsonStore{path: path}, nil
+}
+
+func (s *jsonStore) Save(product string, bucket *Bucket) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	record := jsonBucketRecord{
+		Product:  product,
+		Start:    bucket.Start,
+		Duration: bucket.Duration,
+		Open:     bucket.Open,
+		Close:    bucket.Close,
+		Min:      bucket.Min,
+		Max:      bucket.Max,
+		Trades:   bucket.Trades,
+	}
+	enc := json.NewEncoder(f)
+	return enc.Encode(record)
+}
+
+func (s *jsonStore) Load(product string) ([]*Bucket, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var buckets []*Bucket
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var record jsonBucketRecord
+		if err := dec.Decode(&record); err != nil {
+			return nil, err
+		}
+		if record.Product != product {
+			continue
+		}
+		buckets = append(buckets, &Bucket{
+			Open:     record.Open,
+			Close:    record.Close,
+			Min:      record.Min,
+			Max:      record.Max,
+			Trades:   record.Trades,
+			Start:    record.Start,
+			Duration: record.Duration,
+		})
+	}
+	return buckets, nil
+}
+
+func (s *jsonStore) Close() error {
+	return nil
+}
+
+// Backfill fetches candles for product covering the last d from GDAX's REST
+// historic rates endpoint and converts them into buckets, oldest first.
+func Backfill(client *gdax.Client, product string, candleSize, d time.Duration) ([]*Bucket, error) {
+	if d <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	params := gdax.GetHistoricRatesParams{
+		Start:       now.Add(-d),
+		End:         now,
+		Granularity: int(candleSize.Seconds()),
+	}
+	rates, err := client.GetHistoricRates(product, params)
+	if err != nil {
+		return nil, fmt.Errorf("backfilling %s: %v", product, err)
+	}
+
+	if len(rates) == 0 {
+		return nil, nil
+	}
+
+	buckets := make([]*Bucket, len(rates))
+	for i := range rates {
+		// GDAX returns rates newest-first; we want oldest-first to match
+		// the order buckets are appended in during live processing.
+		r := rates[len(rates)-1-i]
+		buckets[i] = &Bucket{
+			Open:     r.Open,
+			Close:    r.Close,
+			Min:      r.Low,
+			Max:      r.High,
+			Trades:   0,
+			Start:    r.Time,
+			Duration: candleSize,
+		}
+	}
+	return buckets, nil
+}
+
+// mergeBuckets combines store-loaded and backfilled buckets into a single
+// oldest-first slice, dropping backfilled buckets that overlap with what the
+// store already had on disk.
+func mergeBuckets(stored, backfilled []*Bucket) []*Bucket {
+	if len(stored) == 0 {
+		return backfilled
+	}
+	if len(backfilled) == 0 {
+		return stored
+	}
+
+	first, last := stored[0].Start, stored[len(stored)-1].Start
+	merged := make([]*Bucket, 0, len(stored)+len(backfilled))
+	for _, bucket := range backfilled {
+		if bucket.Start.Before(first) {
+			merged = append(merged, bucket)
+		}
+	}
+	merged = append(merged, stored...)
+	for _, bucket := range backfilled {
+		if bucket.Start.After(last) {
+			merged = append(merged, bucket)
+		}
+	}
+	return merged
+}